@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+
+	"github.com/golang/glog"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// newManagedNamespace builds the v1.Namespace that should exist for a Keystone project, stamped
+// with ManagedLabel so it can later be safely identified and cleaned up by this controller.
+func (sc *syncConfig) newManagedNamespace(id string, name string, domain string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sc.formatNamespaceName(id, name, domain),
+			Labels: sc.managedLabels(),
+		},
+	}
+}
+
+// reconcileNamespaces lists Keystone projects, filters them through shouldSyncProject, and
+// converges the set of managed namespaces in Kubernetes: namespaces for projects that no longer
+// pass the filter (or no longer exist) are deleted, and namespaces for newly allowed projects are
+// created. Only namespaces carrying ManagedLabel are ever touched.
+func reconcileNamespaces(client *gophercloud.ServiceClient, kubeClient clientset.Interface, sc *syncConfig) error {
+	pages, err := projects.List(client, projects.ListOpts{}).AllPages()
+	if err != nil {
+		return fmt.Errorf("failed to list keystone projects: %v", err)
+	}
+	allProjects, err := projects.ExtractProjects(pages)
+	if err != nil {
+		return fmt.Errorf("failed to extract keystone projects: %v", err)
+	}
+
+	wanted := make(map[string]*v1.Namespace)
+	for _, p := range allProjects {
+		if !sc.shouldSyncProject(p.ID, p.DomainID) {
+			continue
+		}
+		ns := sc.newManagedNamespace(p.ID, p.Name, p.DomainID)
+		wanted[ns.Name] = ns
+	}
+
+	existing, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{
+		LabelSelector: sc.managedLabelSelector(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list managed namespaces: %v", err)
+	}
+
+	for _, ns := range existing.Items {
+		if _, ok := wanted[ns.Name]; !ok {
+			glog.V(2).Infof("deleting namespace %q, it no longer matches the sync filters", ns.Name)
+			if err := kubeClient.CoreV1().Namespaces().Delete(ns.Name, nil); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	for _, ns := range wanted {
+		if _, err := kubeClient.CoreV1().Namespaces().Create(ns); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
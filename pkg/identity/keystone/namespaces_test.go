@@ -0,0 +1,181 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const fakeProjectsBody = `{
+	"projects": [
+		{"id": "proj1", "name": "project-one", "domain_id": "domain1"},
+		{"id": "proj2", "name": "project-two", "domain_id": "domain2"}
+	]
+}`
+
+// fakeProjectsClient spins up an httptest server simulating Keystone's GET /v3/projects endpoint.
+func fakeProjectsClient(t *testing.T, body string) (*gophercloud.ServiceClient, func()) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, body)
+	})
+
+	srv := httptest.NewServer(mux)
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       srv.URL + "/",
+	}
+
+	return client, srv.Close
+}
+
+func namespaceNames(t *testing.T, kubeClient *fake.Clientset) map[string]bool {
+	t.Helper()
+
+	list, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list namespaces: %v", err)
+	}
+	names := make(map[string]bool, len(list.Items))
+	for _, ns := range list.Items {
+		names[ns.Name] = true
+	}
+	return names
+}
+
+func TestReconcileNamespacesNoFilter(t *testing.T) {
+	client, closeFn := fakeProjectsClient(t, fakeProjectsBody)
+	defer closeFn()
+
+	sc := &syncConfig{NamespaceFormat: "%i"}
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileNamespaces(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileNamespaces() returned an error: %v", err)
+	}
+
+	names := namespaceNames(t, kubeClient)
+	if !names["proj1"] || !names["proj2"] {
+		t.Errorf("reconcileNamespaces() namespaces = %v, want both proj1 and proj2", names)
+	}
+}
+
+func TestReconcileNamespacesDenyList(t *testing.T) {
+	client, closeFn := fakeProjectsClient(t, fakeProjectsBody)
+	defer closeFn()
+
+	sc := &syncConfig{NamespaceFormat: "%i", ProjectBlackList: []string{"proj1"}}
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileNamespaces(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileNamespaces() returned an error: %v", err)
+	}
+
+	names := namespaceNames(t, kubeClient)
+	if names["proj1"] {
+		t.Errorf("reconcileNamespaces() created a namespace for blacklisted proj1: %v", names)
+	}
+	if !names["proj2"] {
+		t.Errorf("reconcileNamespaces() namespaces = %v, want proj2", names)
+	}
+}
+
+func TestReconcileNamespacesAllowList(t *testing.T) {
+	client, closeFn := fakeProjectsClient(t, fakeProjectsBody)
+	defer closeFn()
+
+	sc := &syncConfig{NamespaceFormat: "%i", ProjectWhiteList: []string{"proj1"}}
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileNamespaces(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileNamespaces() returned an error: %v", err)
+	}
+
+	names := namespaceNames(t, kubeClient)
+	if len(names) != 1 || !names["proj1"] {
+		t.Errorf("reconcileNamespaces() namespaces = %v, want only proj1", names)
+	}
+}
+
+func TestReconcileNamespacesDomainFilter(t *testing.T) {
+	client, closeFn := fakeProjectsClient(t, fakeProjectsBody)
+	defer closeFn()
+
+	sc := &syncConfig{NamespaceFormat: "%i", DomainWhiteList: []string{"domain2"}}
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileNamespaces(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileNamespaces() returned an error: %v", err)
+	}
+
+	names := namespaceNames(t, kubeClient)
+	if len(names) != 1 || !names["proj2"] {
+		t.Errorf("reconcileNamespaces() namespaces = %v, want only proj2 (domain2)", names)
+	}
+}
+
+func TestReconcileNamespacesStampsManagedLabelAndDeletesStale(t *testing.T) {
+	client, closeFn := fakeProjectsClient(t, fakeProjectsBody)
+	defer closeFn()
+
+	sc := &syncConfig{NamespaceFormat: "%i", ManagedLabel: "custom.example.com/owner=keystone-sync"}
+	kubeClient := fake.NewSimpleClientset(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "stale",
+			Labels: map[string]string{"custom.example.com/owner": "keystone-sync"},
+		},
+	})
+
+	if err := reconcileNamespaces(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileNamespaces() returned an error: %v", err)
+	}
+
+	list, err := kubeClient.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list namespaces: %v", err)
+	}
+
+	byName := make(map[string]v1.Namespace, len(list.Items))
+	for _, ns := range list.Items {
+		byName[ns.Name] = ns
+	}
+
+	if _, ok := byName["stale"]; ok {
+		t.Error("reconcileNamespaces() left the stale managed namespace in place, want it deleted")
+	}
+
+	ns1, ok := byName["proj1"]
+	if !ok {
+		t.Fatalf("reconcileNamespaces() namespaces = %v, want proj1 to be created", byName)
+	}
+	if ns1.Labels["custom.example.com/owner"] != "keystone-sync" {
+		t.Errorf("reconcileNamespaces() proj1 Labels = %v, want the configured managed label stamped", ns1.Labels)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/roles"
+
+	"github.com/golang/glog"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// roleBindingOwnerLabel marks a (Cluster)RoleBinding as owned by this controller, so that
+// reconcileRoleAssignments never touches bindings it didn't create itself.
+const roleBindingOwnerLabel = "keystone.openstack.org/managed"
+
+// reconcileRoleAssignments lists Keystone role assignments scoped to a project and converges the
+// corresponding RoleBindings in that project's namespace: bindings for assignments that no longer
+// exist are deleted, and bindings for new assignments are created. Each assignment materializes as
+// exactly one Subject, either the user or the group it was granted to. A no-op unless
+// "role_assignments" is enabled in DataTypesToSync.
+func reconcileRoleAssignments(client *gophercloud.ServiceClient, kubeClient clientset.Interface, sc *syncConfig, namespace string, projectID string) error {
+	if !sc.syncs("role_assignments") {
+		return nil
+	}
+
+	assignments, err := listRoleAssignments(client, projectID, "")
+	if err != nil {
+		return fmt.Errorf("failed to list role assignments for project %q: %v", projectID, err)
+	}
+
+	wanted := make(map[string]*rbacv1.RoleBinding)
+	for _, a := range assignments {
+		rb, err := sc.buildRoleBinding(a, namespace)
+		if err != nil {
+			glog.Errorf("skipping role assignment %+v: %v", a, err)
+			continue
+		}
+		wanted[rb.Name] = rb
+	}
+
+	return converge(namesOfRoleBindings(wanted), func() ([]string, error) {
+		existing, err := kubeClient.RbacV1().RoleBindings(namespace).List(metav1.ListOptions{
+			LabelSelector: roleBindingOwnerLabel + "=true",
+		})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(existing.Items))
+		for _, rb := range existing.Items {
+			names = append(names, rb.Name)
+		}
+		return names, nil
+	}, func(name string) error {
+		return kubeClient.RbacV1().RoleBindings(namespace).Delete(name, nil)
+	}, func(name string) error {
+		_, err := kubeClient.RbacV1().RoleBindings(namespace).Create(wanted[name])
+		if apierrors.IsAlreadyExists(err) {
+			_, err = kubeClient.RbacV1().RoleBindings(namespace).Update(wanted[name])
+		}
+		return err
+	})
+}
+
+// reconcileSystemRoleAssignments is the cluster-scoped counterpart of reconcileRoleAssignments: it
+// lists Keystone role assignments with system scope and converges ClusterRoleBindings. A no-op
+// unless "system_role_assignments" is enabled in DataTypesToSync.
+func reconcileSystemRoleAssignments(client *gophercloud.ServiceClient, kubeClient clientset.Interface, sc *syncConfig) error {
+	if !sc.syncs("system_role_assignments") {
+		return nil
+	}
+
+	assignments, err := listRoleAssignments(client, "", "all")
+	if err != nil {
+		return fmt.Errorf("failed to list system role assignments: %v", err)
+	}
+
+	wanted := make(map[string]*rbacv1.ClusterRoleBinding)
+	for _, a := range assignments {
+		crb, err := sc.buildClusterRoleBinding(a)
+		if err != nil {
+			glog.Errorf("skipping system role assignment %+v: %v", a, err)
+			continue
+		}
+		wanted[crb.Name] = crb
+	}
+
+	return converge(namesOfClusterRoleBindings(wanted), func() ([]string, error) {
+		existing, err := kubeClient.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{
+			LabelSelector: roleBindingOwnerLabel + "=true",
+		})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(existing.Items))
+		for _, crb := range existing.Items {
+			names = append(names, crb.Name)
+		}
+		return names, nil
+	}, func(name string) error {
+		return kubeClient.RbacV1().ClusterRoleBindings().Delete(name, nil)
+	}, func(name string) error {
+		_, err := kubeClient.RbacV1().ClusterRoleBindings().Create(wanted[name])
+		if apierrors.IsAlreadyExists(err) {
+			_, err = kubeClient.RbacV1().ClusterRoleBindings().Update(wanted[name])
+		}
+		return err
+	})
+}
+
+// converge is the shared diff/apply loop used by both reconcileRoleAssignments and
+// reconcileSystemRoleAssignments: it lists the currently-owned resource names, deletes the ones
+// that aren't in wantedNames any more, and creates/updates the rest. The actual object to apply
+// for a given name is closed over by the caller's apply callback.
+func converge(wantedNames map[string]bool, list func() ([]string, error), del func(string) error, apply func(string) error) error {
+	existingNames, err := list()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range existingNames {
+		if !wantedNames[name] {
+			if err := del(name); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	for name := range wantedNames {
+		if err := apply(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func namesOfRoleBindings(m map[string]*rbacv1.RoleBinding) map[string]bool {
+	names := make(map[string]bool, len(m))
+	for name := range m {
+		names[name] = true
+	}
+	return names
+}
+
+func namesOfClusterRoleBindings(m map[string]*rbacv1.ClusterRoleBinding) map[string]bool {
+	names := make(map[string]bool, len(m))
+	for name := range m {
+		names[name] = true
+	}
+	return names
+}
+
+// buildRoleBinding converts a Keystone role assignment into the RoleBinding that should exist for
+// it in namespace, resolving the assigned ClusterRole via role_map. The binding has a single
+// Subject, the user or the Keystone group the assignment was granted to (see assignmentSubject).
+func (sc *syncConfig) buildRoleBinding(a roles.RoleAssignment, namespace string) (*rbacv1.RoleBinding, error) {
+	subject, err := sc.assignmentSubject(a)
+	if err != nil {
+		return nil, err
+	}
+
+	name := sc.formatRoleBindingName(a.Role.Name, a.Scope.Project.ID, subjectID(a))
+
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{roleBindingOwnerLabel: "true"},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     sc.clusterRoleFor(a.Role.Name),
+		},
+		Subjects: []rbacv1.Subject{subject},
+	}, nil
+}
+
+// buildClusterRoleBinding is the system-scope equivalent of buildRoleBinding.
+func (sc *syncConfig) buildClusterRoleBinding(a roles.RoleAssignment) (*rbacv1.ClusterRoleBinding, error) {
+	subject, err := sc.assignmentSubject(a)
+	if err != nil {
+		return nil, err
+	}
+
+	name := sc.formatRoleBindingName(a.Role.Name, "system", subjectID(a))
+
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{roleBindingOwnerLabel: "true"},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     sc.clusterRoleFor(a.Role.Name),
+		},
+		Subjects: []rbacv1.Subject{subject},
+	}, nil
+}
+
+// assignmentSubject returns the rbacv1.Subject for a role assignment: a User subject when the
+// assignment is granted directly to a user, or a Group subject (run through GroupFormat) when
+// it's granted to a Keystone group and "user_groups" syncing is enabled.
+func (sc *syncConfig) assignmentSubject(a roles.RoleAssignment) (rbacv1.Subject, error) {
+	switch {
+	case a.User.ID != "":
+		return rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: a.User.Name}, nil
+	case a.Group.ID != "":
+		if !sc.syncs("user_groups") {
+			return rbacv1.Subject{}, fmt.Errorf("assignment is granted to keystone group %q, but \"user_groups\" syncing is disabled", a.Group.Name)
+		}
+		return rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: sc.formatGroupName(a.Group.Name)}, nil
+	default:
+		return rbacv1.Subject{}, fmt.Errorf("role assignment has neither a user nor a group subject")
+	}
+}
+
+func subjectID(a roles.RoleAssignment) string {
+	if a.User.ID != "" {
+		return a.User.ID
+	}
+	return a.Group.ID
+}
+
+// listRoleAssignments lists Keystone role assignments for a project (when projectID is set) or
+// system-wide (when includeSystem is "all"), via the identity v3 role assignments API.
+// IncludeNames is set so that Keystone resolves the role/user/project/group names inline, instead
+// of just their ids -- buildRoleBinding/buildClusterRoleBinding need those names for the generated
+// binding name, the role_map lookup, and the Subject name. Effective is deliberately left unset:
+// Keystone's effective mode resolves group-based assignments into their per-member, user-scoped
+// equivalents, which would mean the Group entries assignmentSubject/GroupFormat depend on never
+// show up.
+func listRoleAssignments(client *gophercloud.ServiceClient, projectID string, includeSystem string) ([]roles.RoleAssignment, error) {
+	opts := roles.ListAssignmentsOpts{
+		ScopeProjectID: projectID,
+		System:         includeSystem,
+		IncludeNames:   true,
+	}
+
+	pages, err := roles.ListAssignments(client, opts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return roles.ExtractRoleAssignments(pages)
+}
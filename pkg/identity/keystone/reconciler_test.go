@@ -0,0 +1,337 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/roles"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func userAssignment(roleName, projectID, userID, userName string) roles.RoleAssignment {
+	a := roles.RoleAssignment{}
+	a.Role.Name = roleName
+	a.Scope.Project.ID = projectID
+	a.User.ID = userID
+	a.User.Name = userName
+	return a
+}
+
+func groupAssignment(roleName, projectID, groupID, groupName string) roles.RoleAssignment {
+	a := roles.RoleAssignment{}
+	a.Role.Name = roleName
+	a.Scope.Project.ID = projectID
+	a.Group.ID = groupID
+	a.Group.Name = groupName
+	return a
+}
+
+func TestAssignmentSubject(t *testing.T) {
+	tests := []struct {
+		name       string
+		sc         syncConfig
+		assignment roles.RoleAssignment
+		wantErr    bool
+		wantKind   string
+		wantName   string
+	}{
+		{
+			name:       "user assignment",
+			sc:         syncConfig{},
+			assignment: userAssignment("admin", "proj1", "u1", "alice"),
+			wantKind:   rbacv1.UserKind,
+			wantName:   "alice",
+		},
+		{
+			name:       "group assignment without user_groups enabled is rejected",
+			sc:         syncConfig{},
+			assignment: groupAssignment("admin", "proj1", "g1", "admins"),
+			wantErr:    true,
+		},
+		{
+			name:       "group assignment with user_groups enabled",
+			sc:         syncConfig{DataTypesToSync: []string{"user_groups"}},
+			assignment: groupAssignment("admin", "proj1", "g1", "admins"),
+			wantKind:   rbacv1.GroupKind,
+			wantName:   "admins",
+		},
+		{
+			name:       "group assignment runs through GroupFormat",
+			sc:         syncConfig{DataTypesToSync: []string{"user_groups"}, GroupFormat: "ks-%g"},
+			assignment: groupAssignment("admin", "proj1", "g1", "admins"),
+			wantKind:   rbacv1.GroupKind,
+			wantName:   "ks-admins",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := tt.sc.assignmentSubject(tt.assignment)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("assignmentSubject() = nil error, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("assignmentSubject() returned an error: %v", err)
+			}
+			if subject.Kind != tt.wantKind || subject.Name != tt.wantName {
+				t.Errorf("assignmentSubject() = %+v, want kind %q name %q", subject, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestBuildRoleBinding(t *testing.T) {
+	sc := syncConfig{RoleFormat: "%r-%p-%u", RoleMap: map[string]string{"admin": "cluster-admin"}}
+
+	rb, err := sc.buildRoleBinding(userAssignment("admin", "proj1", "u1", "alice"), "ns-proj1")
+	if err != nil {
+		t.Fatalf("buildRoleBinding() returned an error: %v", err)
+	}
+
+	if rb.Name != "admin-proj1-u1" {
+		t.Errorf("buildRoleBinding() Name = %q, want admin-proj1-u1", rb.Name)
+	}
+	if rb.Namespace != "ns-proj1" {
+		t.Errorf("buildRoleBinding() Namespace = %q, want ns-proj1", rb.Namespace)
+	}
+	if rb.RoleRef.Name != "cluster-admin" {
+		t.Errorf("buildRoleBinding() RoleRef.Name = %q, want cluster-admin (via role_map)", rb.RoleRef.Name)
+	}
+	if len(rb.Subjects) != 1 || rb.Subjects[0].Name != "alice" {
+		t.Errorf("buildRoleBinding() Subjects = %+v, want a single alice subject", rb.Subjects)
+	}
+	if rb.Labels[roleBindingOwnerLabel] != "true" {
+		t.Errorf("buildRoleBinding() Labels = %v, want the owner label set", rb.Labels)
+	}
+}
+
+func TestBuildRoleBindingUnmappedRole(t *testing.T) {
+	sc := syncConfig{RoleFormat: "%r-%p-%u"}
+
+	rb, err := sc.buildRoleBinding(userAssignment("member", "proj1", "u1", "alice"), "ns-proj1")
+	if err != nil {
+		t.Fatalf("buildRoleBinding() returned an error: %v", err)
+	}
+	if rb.RoleRef.Name != "member" {
+		t.Errorf("buildRoleBinding() RoleRef.Name = %q, want member (unmapped fallback)", rb.RoleRef.Name)
+	}
+}
+
+func TestBuildClusterRoleBinding(t *testing.T) {
+	sc := syncConfig{RoleFormat: "%r-%p-%u", RoleMap: map[string]string{"admin": "cluster-admin"}}
+
+	crb, err := sc.buildClusterRoleBinding(userAssignment("admin", "", "u1", "alice"))
+	if err != nil {
+		t.Fatalf("buildClusterRoleBinding() returned an error: %v", err)
+	}
+
+	if crb.Name != "admin-system-u1" {
+		t.Errorf("buildClusterRoleBinding() Name = %q, want admin-system-u1", crb.Name)
+	}
+	if crb.RoleRef.Name != "cluster-admin" {
+		t.Errorf("buildClusterRoleBinding() RoleRef.Name = %q, want cluster-admin", crb.RoleRef.Name)
+	}
+}
+
+// fakeRoleAssignmentsClient spins up an httptest server simulating Keystone's
+// GET /v3/role_assignments endpoint.
+func fakeRoleAssignmentsClient(t *testing.T, body string) (*gophercloud.ServiceClient, func()) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/role_assignments", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_names") != "true" {
+			t.Errorf("request missing include_names=true: %s", r.URL.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, body)
+	})
+
+	srv := httptest.NewServer(mux)
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       srv.URL + "/",
+	}
+
+	return client, srv.Close
+}
+
+func TestReconcileRoleAssignments(t *testing.T) {
+	client, closeFn := fakeRoleAssignmentsClient(t, `{
+		"role_assignments": [
+			{"role": {"name": "admin"}, "scope": {"project": {"id": "proj1"}}, "user": {"id": "u1", "name": "alice"}}
+		]
+	}`)
+	defer closeFn()
+
+	sc := &syncConfig{
+		DataTypesToSync: []string{"role_assignments"},
+		RoleFormat:      "%r-%p-%u",
+		RoleMap:         map[string]string{"admin": "cluster-admin"},
+	}
+
+	kubeClient := fake.NewSimpleClientset(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stale",
+			Namespace: "ns-proj1",
+			Labels:    map[string]string{roleBindingOwnerLabel: "true"},
+		},
+	})
+
+	if err := reconcileRoleAssignments(client, kubeClient, sc, "ns-proj1", "proj1"); err != nil {
+		t.Fatalf("reconcileRoleAssignments() returned an error: %v", err)
+	}
+
+	bindings, err := kubeClient.RbacV1().RoleBindings("ns-proj1").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list role bindings: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, rb := range bindings.Items {
+		names[rb.Name] = true
+	}
+
+	if names["stale"] {
+		t.Error("reconcileRoleAssignments() left the stale managed RoleBinding in place, want it deleted")
+	}
+	if !names["admin-proj1-u1"] {
+		t.Errorf("reconcileRoleAssignments() didn't create the expected RoleBinding, got %v", names)
+	}
+}
+
+func TestReconcileRoleAssignmentsGroupSubject(t *testing.T) {
+	client, closeFn := fakeRoleAssignmentsClient(t, `{
+		"role_assignments": [
+			{"role": {"name": "admin"}, "scope": {"project": {"id": "proj1"}}, "group": {"id": "g1", "name": "admins"}}
+		]
+	}`)
+	defer closeFn()
+
+	sc := &syncConfig{
+		DataTypesToSync: []string{"role_assignments", "user_groups"},
+		RoleFormat:      "%r-%p-%u",
+		GroupFormat:     "ks-%g",
+	}
+
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileRoleAssignments(client, kubeClient, sc, "ns-proj1", "proj1"); err != nil {
+		t.Fatalf("reconcileRoleAssignments() returned an error: %v", err)
+	}
+
+	bindings, err := kubeClient.RbacV1().RoleBindings("ns-proj1").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list role bindings: %v", err)
+	}
+	if len(bindings.Items) != 1 {
+		t.Fatalf("reconcileRoleAssignments() RoleBindings = %v, want exactly one binding", bindings.Items)
+	}
+
+	subjects := bindings.Items[0].Subjects
+	if len(subjects) != 1 || subjects[0].Kind != rbacv1.GroupKind || subjects[0].Name != "ks-admins" {
+		t.Errorf("reconcileRoleAssignments() Subjects = %+v, want a single Group subject named ks-admins", subjects)
+	}
+}
+
+func TestReconcileRoleAssignmentsSkippedWhenDisabled(t *testing.T) {
+	client, closeFn := fakeRoleAssignmentsClient(t, `{"role_assignments": []}`)
+	defer closeFn()
+
+	sc := &syncConfig{DataTypesToSync: []string{"projects"}}
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileRoleAssignments(client, kubeClient, sc, "ns-proj1", "proj1"); err != nil {
+		t.Fatalf("reconcileRoleAssignments() returned an error: %v", err)
+	}
+
+	bindings, err := kubeClient.RbacV1().RoleBindings("ns-proj1").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list role bindings: %v", err)
+	}
+	if len(bindings.Items) != 0 {
+		t.Errorf("reconcileRoleAssignments() created bindings while role_assignments was disabled: %v", bindings.Items)
+	}
+}
+
+func TestReconcileSystemRoleAssignments(t *testing.T) {
+	client, closeFn := fakeRoleAssignmentsClient(t, `{
+		"role_assignments": [
+			{"role": {"name": "admin"}, "user": {"id": "u1", "name": "alice"}}
+		]
+	}`)
+	defer closeFn()
+
+	sc := &syncConfig{
+		DataTypesToSync: []string{"system_role_assignments"},
+		RoleFormat:      "%r-%p-%u",
+		RoleMap:         map[string]string{"admin": "cluster-admin"},
+	}
+
+	kubeClient := fake.NewSimpleClientset()
+
+	if err := reconcileSystemRoleAssignments(client, kubeClient, sc); err != nil {
+		t.Fatalf("reconcileSystemRoleAssignments() returned an error: %v", err)
+	}
+
+	bindings, err := kubeClient.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list cluster role bindings: %v", err)
+	}
+	if len(bindings.Items) != 1 || bindings.Items[0].Name != "admin-system-u1" {
+		t.Errorf("reconcileSystemRoleAssignments() ClusterRoleBindings = %v, want a single admin-system-u1 binding", bindings.Items)
+	}
+}
+
+func TestConverge(t *testing.T) {
+	wantedNames := map[string]bool{"keep": true, "create": true}
+
+	var deleted []string
+	var applied []string
+
+	err := converge(wantedNames, func() ([]string, error) {
+		return []string{"keep", "stale"}, nil
+	}, func(name string) error {
+		deleted = append(deleted, name)
+		return nil
+	}, func(name string) error {
+		applied = append(applied, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("converge() returned an error: %v", err)
+	}
+
+	if len(deleted) != 1 || deleted[0] != "stale" {
+		t.Errorf("converge() deleted = %v, want [stale]", deleted)
+	}
+	if len(applied) != 2 {
+		t.Errorf("converge() applied = %v, want keep and create", applied)
+	}
+}
@@ -27,21 +27,56 @@ import (
 	"github.com/golang/glog"
 )
 
-// By now only project syncing is supported
-// TODO(mfedosin): Implement syncing of role assignments, system role assignments, and user groups
-var allowedDataTypesToSync = []string{"projects"}
+// Project, role and system role assignments, as well as user groups can be synced
+var allowedDataTypesToSync = []string{"projects", "role_assignments", "system_role_assignments", "user_groups"}
+
+// nameFormats maps a data type kind to the wildcards that its format string is
+// allowed to use, so that validate() and formatName() share a single source of truth.
+var nameFormats = map[string][]string{
+	"namespace": {"%i", "%n", "%d"},
+	"role":      {"%r", "%p", "%u"},
+	"group":     {"%g"},
+}
 
 // syncConfig contains configuration data for synchronization between Keystone and Kubernetes
 type syncConfig struct {
-	// List containing possible data types to sync. Now only "projects" are supported.
+	// List containing possible data types to sync. Can contain "projects", "role_assignments",
+	// "system_role_assignments" and "user_groups".
 	DataTypesToSync []string `yaml:"data_types_to_sync"`
 
 	// Format of automatically created namespace name. Can contain wildcards %i and %n,
 	// corresponding to project id and project name respectively.
 	NamespaceFormat string `yaml:"namespace_format"`
 
-	// List of project ids to exclude from syncing.
+	// Format of automatically created RoleBinding/ClusterRoleBinding name. Can contain wildcards
+	// %r, %p and %u, corresponding to the Keystone role name, project id and user id respectively.
+	// For an assignment granted to a Keystone group, %u is replaced by the group id.
+	RoleFormat string `yaml:"role_format"`
+
+	// Format of the Kubernetes Group subject name projected from a Keystone group. Can contain
+	// the wildcard %g, corresponding to the Keystone group name.
+	GroupFormat string `yaml:"group_format"`
+
+	// RoleMap maps a Keystone role name to the Kubernetes ClusterRole name that the generated
+	// (Cluster)RoleBinding should reference, e.g. "admin": "cluster-admin".
+	RoleMap map[string]string `yaml:"role_map"`
+
+	// List of project ids to exclude from syncing. Mutually exclusive with ProjectWhiteList.
 	ProjectBlackList []string `yaml:"projects_black_list"`
+
+	// List of project ids to exclusively sync. When non-empty, only these projects (further
+	// filtered by DomainWhiteList, if set) are materialized as namespaces. Mutually exclusive
+	// with ProjectBlackList.
+	ProjectWhiteList []string `yaml:"projects_white_list"`
+
+	// List of Keystone domain ids to exclusively sync. When non-empty, only projects that belong
+	// to one of these domains are materialized as namespaces.
+	DomainWhiteList []string `yaml:"domains_white_list"`
+
+	// ManagedLabel is the "key=value" label stamped on every namespace this controller creates.
+	// Only namespaces carrying this label are ever considered for deletion, so flipping between
+	// allow/deny modes never touches namespaces the controller didn't create itself.
+	ManagedLabel string `yaml:"managed_label"`
 }
 
 func (sc *syncConfig) validate() error {
@@ -49,16 +84,42 @@ func (sc *syncConfig) validate() error {
 	if !strings.Contains(sc.NamespaceFormat, "%i") {
 		return fmt.Errorf("format string should comprise a %%i substring (keystone project id)")
 	}
+	if err := validateNameFormat("namespace", sc.NamespaceFormat); err != nil {
+		return err
+	}
 
-	// By convention, the names should be up to maximum length of 63 characters and consist of
-	// lower and upper case alphanumeric characters, -, _ and .
-	ts := strings.Replace(sc.NamespaceFormat, "%i", "aa", -1)
-	ts = strings.Replace(ts, "%n", "aa", -1)
-	ts = strings.Replace(ts, "%d", "aa", -1)
+	for _, dt := range sc.DataTypesToSync {
+		if dt == "role_assignments" || dt == "system_role_assignments" {
+			if sc.RoleFormat == "" {
+				return fmt.Errorf("role_format must be set when %q syncing is enabled", dt)
+			}
+			if !strings.Contains(sc.RoleFormat, "%r") {
+				return fmt.Errorf("role_format should comprise a %%r substring (keystone role name)")
+			}
+			if err := validateNameFormat("role", sc.RoleFormat); err != nil {
+				return err
+			}
+		}
+	}
+	if sc.GroupFormat != "" {
+		if !strings.Contains(sc.GroupFormat, "%g") {
+			return fmt.Errorf("group_format should comprise a %%g substring (keystone group name)")
+		}
+		if err := validateNameFormat("group", sc.GroupFormat); err != nil {
+			return err
+		}
+	}
 
-	re := regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*[a-zA-Z0-9]$")
-	if !re.MatchString(ts) {
-		return fmt.Errorf("namespace name must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character")
+	// ProjectBlackList and ProjectWhiteList are mutually exclusive: either the controller excludes
+	// a handful of projects, or it only ever looks at an explicit allow list.
+	if len(sc.ProjectBlackList) > 0 && len(sc.ProjectWhiteList) > 0 {
+		return fmt.Errorf("projects_black_list and projects_white_list are mutually exclusive")
+	}
+
+	if sc.ManagedLabel != "" {
+		if _, _, err := splitLabel(sc.ManagedLabel); err != nil {
+			return err
+		}
 	}
 
 	// Check that only allowed data types are enabled for synchronization
@@ -82,28 +143,165 @@ func (sc *syncConfig) validate() error {
 	return nil
 }
 
-// formatNamespaceName generates a namespace name, based on format string
-func (sc *syncConfig) formatNamespaceName(id string, name string, domain string) string {
-	res := strings.Replace(sc.NamespaceFormat, "%i", id, -1)
-	res = strings.Replace(res, "%n", name, -1)
-	res = strings.Replace(res, "%d", domain, -1)
+// validateNameFormat checks a format string against the wildcards registered for kind in
+// nameFormats, substituting every known wildcard with an innocuous placeholder and then making
+// sure the result consists of lower and upper case alphanumeric characters, -, _ and ., starting
+// and ending with an alphanumeric character. The length is not checked here: names longer than
+// 63 characters are replaced by a fallback in formatName.
+func validateNameFormat(kind, format string) error {
+	ts := format
+	for _, wildcard := range nameFormats[kind] {
+		ts = strings.Replace(ts, wildcard, "aa", -1)
+	}
+
+	re := regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*[a-zA-Z0-9]$")
+	if !re.MatchString(ts) {
+		return fmt.Errorf("%s_format must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character", kind)
+	}
+
+	return nil
+}
+
+// formatName generates a resource name for the given kind ("namespace", "role" or "group"), based on the
+// corresponding format string from syncConfig and the supplied wildcard substitutions. If the
+// generated name exceeds the 63 character limit, fallback is returned instead and a warning is
+// logged.
+func (sc *syncConfig) formatName(kind string, vars map[string]string, fallback string) string {
+	var format string
+	switch kind {
+	case "namespace":
+		format = sc.NamespaceFormat
+	case "role":
+		format = sc.RoleFormat
+	case "group":
+		format = sc.GroupFormat
+	default:
+		glog.Errorf("formatName called with unknown kind %q", kind)
+		return fallback
+	}
+
+	res := format
+	for wildcard, value := range vars {
+		res = strings.Replace(res, wildcard, value, -1)
+	}
 
 	if len(res) > 63 {
-		glog.Warningf("Generated namespace name '%v' exceeds the maximum possible length of 63 characters. Just Keystone project id '%v' will be used as the namespace name.", res, id)
-		return id
+		glog.Warningf("Generated %v name '%v' exceeds the maximum possible length of 63 characters. '%v' will be used instead.", kind, res, fallback)
+		return fallback
 	}
 
 	return res
 }
 
+// formatNamespaceName generates a namespace name, based on format string
+func (sc *syncConfig) formatNamespaceName(id string, name string, domain string) string {
+	return sc.formatName("namespace", map[string]string{"%i": id, "%n": name, "%d": domain}, id)
+}
+
+// formatRoleBindingName generates a (Cluster)RoleBinding name, based on the role format string
+func (sc *syncConfig) formatRoleBindingName(role string, project string, user string) string {
+	return sc.formatName("role", map[string]string{"%r": role, "%p": project, "%u": user}, fmt.Sprintf("%s-%s-%s", role, project, user))
+}
+
+// formatGroupName generates the name used for the Kubernetes Group subject that represents a
+// Keystone group, based on the group format string. If GroupFormat is unset, the Keystone group
+// name is used verbatim.
+func (sc *syncConfig) formatGroupName(group string) string {
+	if sc.GroupFormat == "" {
+		return group
+	}
+	return sc.formatName("group", map[string]string{"%g": group}, group)
+}
+
+// clusterRoleFor returns the Kubernetes ClusterRole that a Keystone role should be mapped to,
+// falling back to the Keystone role name itself when role_map doesn't have an entry for it.
+func (sc *syncConfig) clusterRoleFor(keystoneRole string) string {
+	if cr, ok := sc.RoleMap[keystoneRole]; ok {
+		return cr
+	}
+	return keystoneRole
+}
+
+// defaultManagedLabel is stamped on every namespace created by the controller, unless the
+// operator overrides ManagedLabel in the sync config.
+const defaultManagedLabel = "keystone.openstack.org/managed=true"
+
 // newSyncConfig defines the default values for syncConfig
 func newSyncConfig() syncConfig {
 	return syncConfig{
 		// by default namespace name is a string containing just keystone project id
 		NamespaceFormat: "%i",
+		// by default RoleBinding/ClusterRoleBinding names are "role-project-user"
+		RoleFormat: "%r-%p-%u",
 		// by default all possible data types are enabled
 		DataTypesToSync: allowedDataTypesToSync,
+		// by default namespaces created by the controller are labeled so they can be safely
+		// cleaned up later
+		ManagedLabel: defaultManagedLabel,
+	}
+}
+
+// splitLabel parses a "key=value" label string, as used for ManagedLabel.
+func splitLabel(label string) (key string, value string, err error) {
+	parts := strings.SplitN(label, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("managed_label must be in the form 'key=value', got %q", label)
+	}
+	return parts[0], parts[1], nil
+}
+
+// managedLabels returns the label map that should be stamped on every namespace this controller
+// creates, falling back to defaultManagedLabel when ManagedLabel isn't set.
+func (sc *syncConfig) managedLabels() map[string]string {
+	label := sc.ManagedLabel
+	if label == "" {
+		label = defaultManagedLabel
+	}
+	key, value, err := splitLabel(label)
+	if err != nil {
+		glog.Errorf("invalid managed_label %q, falling back to default: %v", label, err)
+		key, value, _ = splitLabel(defaultManagedLabel)
+	}
+	return map[string]string{key: value}
+}
+
+// managedLabelSelector returns the ManagedLabel formatted as a label selector, for listing only
+// the namespaces this controller owns.
+func (sc *syncConfig) managedLabelSelector() string {
+	for k, v := range sc.managedLabels() {
+		return fmt.Sprintf("%s=%s", k, v)
+	}
+	return defaultManagedLabel
+}
+
+// shouldSyncProject reports whether a Keystone project should be materialized as a namespace,
+// applying DomainWhiteList first and then ProjectWhiteList/ProjectBlackList. The two project
+// lists are mutually exclusive, enforced by validate().
+func (sc *syncConfig) shouldSyncProject(projectID string, domainID string) bool {
+	if len(sc.DomainWhiteList) > 0 && !contains(sc.DomainWhiteList, domainID) {
+		return false
+	}
+
+	if len(sc.ProjectWhiteList) > 0 {
+		return contains(sc.ProjectWhiteList, projectID)
+	}
+
+	return !contains(sc.ProjectBlackList, projectID)
+}
+
+// syncs reports whether dataType is enabled in DataTypesToSync, e.g. "role_assignments",
+// "system_role_assignments" or "user_groups".
+func (sc *syncConfig) syncs(dataType string) bool {
+	return contains(sc.DataTypesToSync, dataType)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
 	}
+	return false
 }
 
 // newSyncConfigFromFile loads a sync config from a file
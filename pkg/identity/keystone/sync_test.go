@@ -0,0 +1,228 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package keystone
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sc      syncConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults are valid",
+			sc:   newSyncConfig(),
+		},
+		{
+			name: "namespace format missing %i",
+			sc: syncConfig{
+				NamespaceFormat: "%n",
+				DataTypesToSync: []string{"projects"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "namespace format with invalid characters",
+			sc: syncConfig{
+				NamespaceFormat: "ns_%i_!",
+				DataTypesToSync: []string{"projects"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "role assignments without role_format",
+			sc: syncConfig{
+				NamespaceFormat: "%i",
+				DataTypesToSync: []string{"role_assignments"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "role assignments with role_format missing %r",
+			sc: syncConfig{
+				NamespaceFormat: "%i",
+				RoleFormat:      "%p-%u",
+				DataTypesToSync: []string{"role_assignments"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "role assignments with valid role_format",
+			sc: syncConfig{
+				NamespaceFormat: "%i",
+				RoleFormat:      "%r-%p-%u",
+				DataTypesToSync: []string{"role_assignments"},
+			},
+		},
+		{
+			name: "unsupported data type",
+			sc: syncConfig{
+				NamespaceFormat: "%i",
+				DataTypesToSync: []string{"flavors"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sc.validate()
+			if tt.wantErr && err == nil {
+				t.Errorf("validate() = nil, want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestFormatNamespaceName(t *testing.T) {
+	sc := syncConfig{NamespaceFormat: "ns-%n-%i"}
+
+	got := sc.formatNamespaceName("1234", "myproject", "default")
+	want := "ns-myproject-1234"
+	if got != want {
+		t.Errorf("formatNamespaceName() = %q, want %q", got, want)
+	}
+
+	sc.NamespaceFormat = "ns-" + strings.Repeat("x", 60) + "-%i"
+	if got := sc.formatNamespaceName("1234", "myproject", "default"); got != "1234" {
+		t.Errorf("formatNamespaceName() with an over-long format = %q, want fallback %q", got, "1234")
+	}
+}
+
+func TestFormatRoleBindingName(t *testing.T) {
+	sc := syncConfig{RoleFormat: "%r-%p-%u"}
+
+	got := sc.formatRoleBindingName("admin", "proj1", "user1")
+	want := "admin-proj1-user1"
+	if got != want {
+		t.Errorf("formatRoleBindingName() = %q, want %q", got, want)
+	}
+}
+
+func TestClusterRoleFor(t *testing.T) {
+	sc := syncConfig{RoleMap: map[string]string{"admin": "cluster-admin"}}
+
+	if got := sc.clusterRoleFor("admin"); got != "cluster-admin" {
+		t.Errorf("clusterRoleFor(admin) = %q, want cluster-admin", got)
+	}
+	if got := sc.clusterRoleFor("member"); got != "member" {
+		t.Errorf("clusterRoleFor(member) = %q, want member (unmapped fallback)", got)
+	}
+}
+
+func TestShouldSyncProject(t *testing.T) {
+	tests := []struct {
+		name      string
+		sc        syncConfig
+		projectID string
+		domainID  string
+		want      bool
+	}{
+		{
+			name:      "no lists: everything syncs",
+			sc:        syncConfig{},
+			projectID: "proj1",
+			domainID:  "domain1",
+			want:      true,
+		},
+		{
+			name:      "denylist: blacklisted project is skipped",
+			sc:        syncConfig{ProjectBlackList: []string{"proj1"}},
+			projectID: "proj1",
+			domainID:  "domain1",
+			want:      false,
+		},
+		{
+			name:      "denylist: other projects still sync",
+			sc:        syncConfig{ProjectBlackList: []string{"proj1"}},
+			projectID: "proj2",
+			domainID:  "domain1",
+			want:      true,
+		},
+		{
+			name:      "allowlist: listed project syncs",
+			sc:        syncConfig{ProjectWhiteList: []string{"proj1"}},
+			projectID: "proj1",
+			domainID:  "domain1",
+			want:      true,
+		},
+		{
+			name:      "allowlist: unlisted project is skipped",
+			sc:        syncConfig{ProjectWhiteList: []string{"proj1"}},
+			projectID: "proj2",
+			domainID:  "domain1",
+			want:      false,
+		},
+		{
+			name:      "domain filter excludes projects outside the whitelisted domains",
+			sc:        syncConfig{DomainWhiteList: []string{"domain1"}},
+			projectID: "proj1",
+			domainID:  "domain2",
+			want:      false,
+		},
+		{
+			name:      "domain filter combines with the allowlist",
+			sc:        syncConfig{ProjectWhiteList: []string{"proj1"}, DomainWhiteList: []string{"domain1"}},
+			projectID: "proj1",
+			domainID:  "domain2",
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sc.shouldSyncProject(tt.projectID, tt.domainID); got != tt.want {
+				t.Errorf("shouldSyncProject(%q, %q) = %v, want %v", tt.projectID, tt.domainID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProjectListsMutuallyExclusive(t *testing.T) {
+	sc := syncConfig{
+		NamespaceFormat:  "%i",
+		DataTypesToSync:  []string{"projects"},
+		ProjectBlackList: []string{"proj1"},
+		ProjectWhiteList: []string{"proj2"},
+	}
+
+	if err := sc.validate(); err == nil {
+		t.Error("validate() = nil, want error for mutually exclusive project lists")
+	}
+}
+
+func TestManagedLabels(t *testing.T) {
+	sc := syncConfig{}
+	labels := sc.managedLabels()
+	if labels["keystone.openstack.org/managed"] != "true" {
+		t.Errorf("managedLabels() = %v, want default managed label", labels)
+	}
+
+	sc.ManagedLabel = "custom.example.com/owner=keystone-sync"
+	labels = sc.managedLabels()
+	if labels["custom.example.com/owner"] != "keystone-sync" {
+		t.Errorf("managedLabels() = %v, want custom managed label", labels)
+	}
+}
+
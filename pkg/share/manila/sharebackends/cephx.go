@@ -0,0 +1,49 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharebackends
+
+import (
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+// grantAccessCephx grants access to a Ceph share. Since Ceph share keys are generated by the Ceph
+// backend, they're not contained in the response from shares.GrantAccess(), but have to be
+// queried for separately by subsequent ListAccessRights call(s).
+func grantAccessCephx(args *GrantAccessArgs) (*shares.AccessRight, error) {
+	accessOpts := shares.GrantAccessOpts{
+		AccessType:  "cephx",
+		AccessTo:    args.Share.Name,
+		AccessLevel: "rw",
+	}
+
+	if _, err := shares.GrantAccess(args.Client, args.Share.ID, accessOpts).Extract(); err != nil {
+		return nil, err
+	}
+
+	return waitForAccessRight(args.Client, args.Share.ID, func(accessRights []shares.AccessRight) (*shares.AccessRight, bool, error) {
+		ar, err := singleAccessRight(accessRights)
+		if err != nil || ar == nil {
+			return nil, false, err
+		}
+
+		if ar.AccessKey == "" {
+			return nil, false, nil
+		}
+
+		return ar, true, nil
+	}, args.WaitOpts)
+}
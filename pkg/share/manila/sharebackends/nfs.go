@@ -0,0 +1,59 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharebackends
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+// nfsAccessTypes are the access rule types Manila's NFS driver accepts: access by client IP,
+// by NIS/LDAP username, or by TLS client certificate.
+var nfsAccessTypes = map[string]bool{"ip": true, "user": true, "cert": true}
+
+// grantAccessNFS grants access to an NFS share. Unlike Ceph, NFS access rules carry no secret to
+// wait on, so a rule is considered ready as soon as Manila reports its state as "active", or
+// reports no state for it at all.
+func grantAccessNFS(args *GrantAccessArgs) (*shares.AccessRight, error) {
+	if !nfsAccessTypes[args.AccessType] {
+		return nil, fmt.Errorf("unsupported NFS access type %q: must be one of ip, user, cert", args.AccessType)
+	}
+
+	accessOpts := shares.GrantAccessOpts{
+		AccessType:  args.AccessType,
+		AccessTo:    args.AccessTo,
+		AccessLevel: "rw",
+	}
+
+	if _, err := shares.GrantAccess(args.Client, args.Share.ID, accessOpts).Extract(); err != nil {
+		return nil, err
+	}
+
+	return waitForAccessRight(args.Client, args.Share.ID, func(accessRights []shares.AccessRight) (*shares.AccessRight, bool, error) {
+		ar, err := singleAccessRight(accessRights)
+		if err != nil || ar == nil {
+			return nil, false, err
+		}
+
+		if ar.State != "" && ar.State != "active" {
+			return nil, false, nil
+		}
+
+		return ar, true, nil
+	}, args.WaitOpts)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharebackends
+
+import (
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+// WaitOptions controls how waitForAccessRight polls Manila while waiting for a just-granted
+// access rule to become ready, e.g. for Ceph or CIFS to finish populating an access key.
+type WaitOptions struct {
+	// Timeout is the maximum time to wait before giving up. Defaults to 120 seconds if zero.
+	Timeout time.Duration
+
+	// Interval is the delay between polls, before backoff is applied. Defaults to 1 second if
+	// zero.
+	Interval time.Duration
+
+	// BackoffFactor multiplies Interval after every poll that doesn't find the access rule ready.
+	// Values <= 1 disable backoff, so Interval is used unchanged throughout the wait.
+	BackoffFactor float64
+
+	// MaxInterval caps the polling interval once BackoffFactor has grown it. Defaults to Interval
+	// if zero, which effectively disables backoff regardless of BackoffFactor.
+	MaxInterval time.Duration
+}
+
+// GrantAccessArgs bundles everything a backend's grant-access function needs to grant access to a
+// share and wait for the resulting access rule to become usable.
+type GrantAccessArgs struct {
+	Client *gophercloud.ServiceClient
+	Share  *shares.Share
+
+	// AccessType is the Manila access rule type to request for NFS shares: "ip", "user" or
+	// "cert". Unused for cephx and CIFS, which always request "cephx" and "user" respectively.
+	AccessType string
+
+	// AccessTo is the subject of the access rule (an IP address, a username, or a certificate
+	// common name, depending on AccessType). Unused for cephx, which always grants access to the
+	// share's own name.
+	AccessTo string
+
+	// WaitOpts tunes how long and how aggressively to poll Manila for the access rule to become
+	// ready. The zero value falls back to waitForAccessRight's defaults.
+	WaitOpts WaitOptions
+}
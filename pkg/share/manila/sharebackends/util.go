@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
 	"k8s.io/api/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
@@ -60,42 +59,3 @@ func createSecret(name, namespace string, cs clientset.Interface, data map[strin
 func deleteSecret(name, namespace string, cs clientset.Interface) error {
 	return cs.CoreV1().Secrets(namespace).Delete(name, nil)
 }
-
-// Grants access to Ceph share. Since Ceph share keys are generated by Ceph backend,
-// they're not contained in the response from shares.GrantAccess(), but have to be
-// queried for separately by subsequent ListAccessRights call(s)
-func grantAccessCephx(args *GrantAccessArgs) (*shares.AccessRight, error) {
-	accessOpts := shares.GrantAccessOpts{
-		AccessType:  "cephx",
-		AccessTo:    args.Share.Name,
-		AccessLevel: "rw",
-	}
-
-	if _, err := shares.GrantAccess(args.Client, args.Share.ID, accessOpts).Extract(); err != nil {
-		return nil, err
-	}
-
-	var accessRight shares.AccessRight
-
-	err := gophercloud.WaitFor(120, func() (bool, error) {
-		accessRights, err := shares.ListAccessRights(args.Client, args.Share.ID).Extract()
-		if err != nil {
-			return false, err
-		}
-
-		if len(accessRights) > 1 {
-			return false, fmt.Errorf("unexpected number of access rules: got %d, expected 1", len(accessRights))
-		} else if len(accessRights) == 0 {
-			return false, nil
-		}
-
-		if accessRights[0].AccessKey != "" {
-			accessRight = accessRights[0]
-			return true, nil
-		}
-
-		return false, nil
-	})
-
-	return &accessRight, err
-}
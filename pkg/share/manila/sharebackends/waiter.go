@@ -0,0 +1,99 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharebackends
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+const (
+	defaultWaitTimeout  = 120 * time.Second
+	defaultWaitInterval = 1 * time.Second
+)
+
+// waitForAccessRight polls ListAccessRights for shareID until pred reports the access right it's
+// interested in is ready, pred returns an error, or opts.Timeout elapses. Transient errors from
+// ListAccessRights itself (e.g. a backend returning 5xx while the share is still settling) don't
+// abort the wait; they're retried like any other not-ready-yet result, and only surface if the
+// deadline is reached.
+func waitForAccessRight(client *gophercloud.ServiceClient, shareID string, pred func([]shares.AccessRight) (*shares.AccessRight, bool, error), opts WaitOptions) (*shares.AccessRight, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+	maxInterval := opts.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = interval
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for {
+		accessRights, err := shares.ListAccessRights(client, shareID).Extract()
+		if err != nil {
+			lastErr = err
+		} else {
+			ar, ready, err := pred(accessRights)
+			if err != nil {
+				return nil, err
+			}
+			if ready {
+				return ar, nil
+			}
+			lastErr = nil
+		}
+
+		if time.Now().After(deadline) {
+			if lastErr != nil {
+				return nil, fmt.Errorf("timed out waiting for access right on share %q: %v", shareID, lastErr)
+			}
+			return nil, fmt.Errorf("timed out waiting for access right on share %q", shareID)
+		}
+
+		time.Sleep(interval)
+
+		if opts.BackoffFactor > 1 {
+			interval = time.Duration(float64(interval) * opts.BackoffFactor)
+			if interval > maxInterval {
+				interval = maxInterval
+			}
+		}
+	}
+}
+
+// singleAccessRight returns the one access right in accessRights, nil if there are none yet (the
+// rule hasn't shown up in Manila's listing), or an error if there's more than one, since every
+// backend here only ever grants a single access rule per share.
+func singleAccessRight(accessRights []shares.AccessRight) (*shares.AccessRight, error) {
+	switch len(accessRights) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &accessRights[0], nil
+	default:
+		return nil, fmt.Errorf("unexpected number of access rules: got %d, expected 1", len(accessRights))
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharebackends
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+// fakeShareClient spins up an httptest server that simulates the "list access rights" action
+// endpoint: the first failCount requests return a 503, and after that it serves one response per
+// call from responses in order, repeating the last one once exhausted.
+func fakeShareClient(t *testing.T, failCount int, responses ...string) (*gophercloud.ServiceClient, func(), *int) {
+	t.Helper()
+
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shares/share-1/action", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failCount {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		idx := calls - failCount - 1
+		if idx >= len(responses) {
+			idx = len(responses) - 1
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, responses[idx])
+	})
+
+	srv := httptest.NewServer(mux)
+
+	client := &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{},
+		Endpoint:       srv.URL + "/",
+	}
+
+	return client, srv.Close, &calls
+}
+
+func TestWaitForAccessRightCephxSlowKeyPopulation(t *testing.T) {
+	client, closeFn, calls := fakeShareClient(t, 0,
+		`{"access_list": [{"id": "ar-1", "access_type": "cephx", "access_key": ""}]}`,
+		`{"access_list": [{"id": "ar-1", "access_type": "cephx", "access_key": ""}]}`,
+		`{"access_list": [{"id": "ar-1", "access_type": "cephx", "access_key": "AQA=="}]}`,
+	)
+	defer closeFn()
+
+	ar, err := waitForAccessRight(client, "share-1", func(accessRights []shares.AccessRight) (*shares.AccessRight, bool, error) {
+		ar, err := singleAccessRight(accessRights)
+		if err != nil || ar == nil || ar.AccessKey == "" {
+			return nil, false, err
+		}
+		return ar, true, nil
+	}, WaitOptions{Timeout: 2 * time.Second, Interval: 10 * time.Millisecond})
+
+	if err != nil {
+		t.Fatalf("waitForAccessRight() returned an error: %v", err)
+	}
+	if ar.AccessKey != "AQA==" {
+		t.Errorf("waitForAccessRight() AccessKey = %q, want AQA==", ar.AccessKey)
+	}
+	if *calls < 3 {
+		t.Errorf("waitForAccessRight() polled %d times, want at least 3", *calls)
+	}
+}
+
+func TestWaitForAccessRightToleratesTransientErrors(t *testing.T) {
+	client, closeFn, calls := fakeShareClient(t, 2,
+		`{"access_list": [{"id": "ar-1", "access_type": "ip", "state": "active"}]}`,
+	)
+	defer closeFn()
+
+	ar, err := waitForAccessRight(client, "share-1", func(accessRights []shares.AccessRight) (*shares.AccessRight, bool, error) {
+		ar, err := singleAccessRight(accessRights)
+		if err != nil || ar == nil {
+			return nil, false, err
+		}
+		if ar.State != "" && ar.State != "active" {
+			return nil, false, nil
+		}
+		return ar, true, nil
+	}, WaitOptions{Timeout: 2 * time.Second, Interval: 10 * time.Millisecond})
+
+	if err != nil {
+		t.Fatalf("waitForAccessRight() returned an error: %v", err)
+	}
+	if ar.State != "active" {
+		t.Errorf("waitForAccessRight() State = %q, want active", ar.State)
+	}
+	if *calls < 3 {
+		t.Errorf("waitForAccessRight() polled %d times, want at least 3 (2 failures + 1 success)", *calls)
+	}
+}
+
+func TestWaitForAccessRightTimesOut(t *testing.T) {
+	client, closeFn, _ := fakeShareClient(t, 0,
+		`{"access_list": [{"id": "ar-1", "access_type": "cephx", "access_key": ""}]}`,
+	)
+	defer closeFn()
+
+	_, err := waitForAccessRight(client, "share-1", func(accessRights []shares.AccessRight) (*shares.AccessRight, bool, error) {
+		ar, err := singleAccessRight(accessRights)
+		if err != nil || ar == nil || ar.AccessKey == "" {
+			return nil, false, err
+		}
+		return ar, true, nil
+	}, WaitOptions{Timeout: 30 * time.Millisecond, Interval: 10 * time.Millisecond})
+
+	if err == nil {
+		t.Error("waitForAccessRight() = nil error, want a timeout error")
+	}
+}
+
+func TestSingleAccessRight(t *testing.T) {
+	if ar, err := singleAccessRight(nil); err != nil || ar != nil {
+		t.Errorf("singleAccessRight(nil) = (%v, %v), want (nil, nil)", ar, err)
+	}
+
+	one := []shares.AccessRight{{ID: "ar-1"}}
+	if ar, err := singleAccessRight(one); err != nil || ar == nil || ar.ID != "ar-1" {
+		t.Errorf("singleAccessRight(one) = (%v, %v), want the single element", ar, err)
+	}
+
+	two := []shares.AccessRight{{ID: "ar-1"}, {ID: "ar-2"}}
+	if _, err := singleAccessRight(two); err == nil {
+		t.Error("singleAccessRight(two) = nil error, want an error for more than one access rule")
+	}
+}